@@ -0,0 +1,513 @@
+package xero2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/plybit/goth"
+)
+
+const accountingAPIBase = "https://api.xero.com/api.xro/2.0"
+
+// accountingPageSize is the number of records Xero returns per page for
+// paginated Accounting API endpoints.
+const accountingPageSize = 100
+
+// RateLimit reflects the per-tenant rate limit headers Xero returns on
+// every Accounting API response, so callers can back off before they
+// exhaust their quota. See https://developer.xero.com/documentation/guides/oauth2/limits/
+type RateLimit struct {
+	DayLimitRemaining    int
+	MinLimitRemaining    int
+	AppMinLimitRemaining int
+	RetryAfter           time.Duration
+}
+
+// ListParams narrows a list request the way the Accounting API expects:
+// a `where` clause, an `order` clause, and a page number. IfModifiedSince,
+// when non-zero, is sent as the `If-Modified-Since` header so the caller
+// only gets records changed since that time.
+type ListParams struct {
+	Where           string
+	Order           string
+	Page            int
+	IfModifiedSince time.Time
+}
+
+func (lp ListParams) query() url.Values {
+	q := url.Values{}
+	if lp.Where != "" {
+		q.Set("where", lp.Where)
+	}
+	if lp.Order != "" {
+		q.Set("order", lp.Order)
+	}
+	if lp.Page > 0 {
+		q.Set("page", strconv.Itoa(lp.Page))
+	}
+	return q
+}
+
+// APIClient is a small, typed client for Xero's Accounting API, built on
+// top of an already-authenticated session. Obtain one via Provider.APIClient.
+type APIClient struct {
+	provider *Provider
+	tenantID string
+
+	accessToken string
+
+	// baseURL defaults to accountingAPIBase; it's only overridden in
+	// tests so they can point requests at an httptest.Server.
+	baseURL string
+
+	// RetryOn429 makes do() sleep for Retry-After and retry once when
+	// Xero responds with 429 Too Many Requests, instead of returning an
+	// error immediately.
+	RetryOn429 bool
+
+	// RateLimit reflects the headers from the most recently completed
+	// request.
+	RateLimit RateLimit
+}
+
+// APIClient returns a typed Accounting API client that authenticates as
+// session and scopes every request to tenantID via the Xero-Tenant-Id
+// header.
+func (p *Provider) APIClient(session goth.Session, tenantID string) *APIClient {
+	sess := session.(*Session)
+	return &APIClient{
+		provider:    p,
+		tenantID:    tenantID,
+		accessToken: sess.AccessToken,
+		baseURL:     accountingAPIBase,
+	}
+}
+
+// maxRetryOn429 bounds how many times do() will retry a request after a
+// 429 response when RetryOn429 is set, so a caller without a context
+// deadline can't be left retrying forever against a tenant that's stuck
+// over its rate limit.
+const maxRetryOn429 = 1
+
+func (c *APIClient) do(ctx context.Context, method, resource string, query url.Values, ifModifiedSince time.Time, out interface{}) error {
+	return c.doWithRetries(ctx, method, resource, query, ifModifiedSince, out, maxRetryOn429)
+}
+
+func (c *APIClient) doWithRetries(ctx context.Context, method, resource string, query url.Values, ifModifiedSince time.Time, out interface{}, retriesLeft int) error {
+	u := c.baseURL + "/" + resource
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
+	req.Header.Set("Xero-Tenant-Id", c.tenantID)
+	req.Header.Set("Accept", "application/json")
+	if !ifModifiedSince.IsZero() {
+		req.Header.Set("If-Modified-Since", ifModifiedSince.UTC().Format(http.TimeFormat))
+	}
+
+	resp, err := c.provider.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	c.RateLimit = parseRateLimit(resp.Header)
+
+	if resp.StatusCode == http.StatusTooManyRequests && c.RetryOn429 && retriesLeft > 0 {
+		resp.Body.Close()
+		if c.RateLimit.RetryAfter > 0 {
+			select {
+			case <-time.After(c.RateLimit.RetryAfter):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return c.doWithRetries(ctx, method, resource, query, ifModifiedSince, out, retriesLeft-1)
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d requesting %s", c.provider.providerName, resp.StatusCode, resource)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(bytes.NewReader(bits)).Decode(out)
+}
+
+func parseRateLimit(h http.Header) RateLimit {
+	rl := RateLimit{
+		DayLimitRemaining:    parseIntHeader(h, "X-DayLimit-Remaining"),
+		MinLimitRemaining:    parseIntHeader(h, "X-MinLimit-Remaining"),
+		AppMinLimitRemaining: parseIntHeader(h, "X-AppMinLimit-Remaining"),
+	}
+	if retryAfter := h.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			rl.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+	return rl
+}
+
+func parseIntHeader(h http.Header, name string) int {
+	v, err := strconv.Atoi(h.Get(name))
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// Contact is a single Xero Accounting API contact.
+type Contact struct {
+	ContactID    string `json:"ContactID,omitempty"`
+	Name         string `json:"Name,omitempty"`
+	EmailAddress string `json:"EmailAddress,omitempty"`
+}
+
+// Invoice is a single Xero Accounting API invoice.
+type Invoice struct {
+	InvoiceID     string  `json:"InvoiceID,omitempty"`
+	InvoiceNumber string  `json:"InvoiceNumber,omitempty"`
+	Status        string  `json:"Status,omitempty"`
+	Total         float64 `json:"Total,omitempty"`
+}
+
+// Payment is a single Xero Accounting API payment.
+type Payment struct {
+	PaymentID string  `json:"PaymentID,omitempty"`
+	Amount    float64 `json:"Amount,omitempty"`
+	Status    string  `json:"Status,omitempty"`
+}
+
+// Account is a single Xero Accounting API account in the chart of accounts.
+type Account struct {
+	AccountID string `json:"AccountID,omitempty"`
+	Code      string `json:"Code,omitempty"`
+	Name      string `json:"Name,omitempty"`
+	Type      string `json:"Type,omitempty"`
+}
+
+// BankTransaction is a single Xero Accounting API bank transaction.
+type BankTransaction struct {
+	BankTransactionID string  `json:"BankTransactionID,omitempty"`
+	Type              string  `json:"Type,omitempty"`
+	Status            string  `json:"Status,omitempty"`
+	Total             float64 `json:"Total,omitempty"`
+}
+
+// Contacts lists contacts matching params.
+func (c *APIClient) Contacts(ctx context.Context, params ListParams) ([]Contact, error) {
+	var out struct {
+		Contacts []Contact
+	}
+	if err := c.do(ctx, "GET", "Contacts", params.query(), params.IfModifiedSince, &out); err != nil {
+		return nil, err
+	}
+	return out.Contacts, nil
+}
+
+// Contact fetches a single contact by ID.
+func (c *APIClient) Contact(ctx context.Context, id string) (*Contact, error) {
+	var out struct {
+		Contacts []Contact
+	}
+	if err := c.do(ctx, "GET", "Contacts/"+id, nil, time.Time{}, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Contacts) == 0 {
+		return nil, fmt.Errorf("%s: no contact found for id %s", c.provider.providerName, id)
+	}
+	return &out.Contacts[0], nil
+}
+
+// Invoices lists invoices matching params.
+func (c *APIClient) Invoices(ctx context.Context, params ListParams) ([]Invoice, error) {
+	var out struct {
+		Invoices []Invoice
+	}
+	if err := c.do(ctx, "GET", "Invoices", params.query(), params.IfModifiedSince, &out); err != nil {
+		return nil, err
+	}
+	return out.Invoices, nil
+}
+
+// Invoice fetches a single invoice by ID.
+func (c *APIClient) Invoice(ctx context.Context, id string) (*Invoice, error) {
+	var out struct {
+		Invoices []Invoice
+	}
+	if err := c.do(ctx, "GET", "Invoices/"+id, nil, time.Time{}, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Invoices) == 0 {
+		return nil, fmt.Errorf("%s: no invoice found for id %s", c.provider.providerName, id)
+	}
+	return &out.Invoices[0], nil
+}
+
+// Payments lists payments matching params.
+func (c *APIClient) Payments(ctx context.Context, params ListParams) ([]Payment, error) {
+	var out struct {
+		Payments []Payment
+	}
+	if err := c.do(ctx, "GET", "Payments", params.query(), params.IfModifiedSince, &out); err != nil {
+		return nil, err
+	}
+	return out.Payments, nil
+}
+
+// Payment fetches a single payment by ID.
+func (c *APIClient) Payment(ctx context.Context, id string) (*Payment, error) {
+	var out struct {
+		Payments []Payment
+	}
+	if err := c.do(ctx, "GET", "Payments/"+id, nil, time.Time{}, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Payments) == 0 {
+		return nil, fmt.Errorf("%s: no payment found for id %s", c.provider.providerName, id)
+	}
+	return &out.Payments[0], nil
+}
+
+// Accounts lists the chart of accounts matching params.
+func (c *APIClient) Accounts(ctx context.Context, params ListParams) ([]Account, error) {
+	var out struct {
+		Accounts []Account
+	}
+	if err := c.do(ctx, "GET", "Accounts", params.query(), params.IfModifiedSince, &out); err != nil {
+		return nil, err
+	}
+	return out.Accounts, nil
+}
+
+// Account fetches a single account by ID.
+func (c *APIClient) Account(ctx context.Context, id string) (*Account, error) {
+	var out struct {
+		Accounts []Account
+	}
+	if err := c.do(ctx, "GET", "Accounts/"+id, nil, time.Time{}, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Accounts) == 0 {
+		return nil, fmt.Errorf("%s: no account found for id %s", c.provider.providerName, id)
+	}
+	return &out.Accounts[0], nil
+}
+
+// BankTransactions lists bank transactions matching params.
+func (c *APIClient) BankTransactions(ctx context.Context, params ListParams) ([]BankTransaction, error) {
+	var out struct {
+		BankTransactions []BankTransaction
+	}
+	if err := c.do(ctx, "GET", "BankTransactions", params.query(), params.IfModifiedSince, &out); err != nil {
+		return nil, err
+	}
+	return out.BankTransactions, nil
+}
+
+// BankTransaction fetches a single bank transaction by ID.
+func (c *APIClient) BankTransaction(ctx context.Context, id string) (*BankTransaction, error) {
+	var out struct {
+		BankTransactions []BankTransaction
+	}
+	if err := c.do(ctx, "GET", "BankTransactions/"+id, nil, time.Time{}, &out); err != nil {
+		return nil, err
+	}
+	if len(out.BankTransactions) == 0 {
+		return nil, fmt.Errorf("%s: no bank transaction found for id %s", c.provider.providerName, id)
+	}
+	return &out.BankTransactions[0], nil
+}
+
+// Organisations returns the tenant's organisation records.
+func (c *APIClient) Organisations(ctx context.Context) ([]XeroOrganization, error) {
+	var out struct {
+		Organisations []XeroOrganization
+	}
+	if err := c.do(ctx, "GET", "Organisation", nil, time.Time{}, &out); err != nil {
+		return nil, err
+	}
+	return out.Organisations, nil
+}
+
+// pageState is the pagination bookkeeping shared by every *Iterator type
+// below, since the Accounting API's list endpoints are all capped at
+// accountingPageSize records the same way.
+type pageState struct {
+	params ListParams
+	done   bool
+}
+
+func newPageState(params ListParams) pageState {
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	return pageState{params: params}
+}
+
+// advance records that a page of n records was just fetched and moves on
+// to the next one, marking iteration done once a short page is seen.
+func (s *pageState) advance(n int) {
+	s.params.Page++
+	if n < accountingPageSize {
+		s.done = true
+	}
+}
+
+// ContactIterator pages through Contacts one page at a time, since the
+// Accounting API caps list responses at accountingPageSize records.
+type ContactIterator struct {
+	client *APIClient
+	pageState
+}
+
+// ContactsIterator returns an iterator over every contact matching params,
+// starting from params.Page (or page 1 if unset).
+func (c *APIClient) ContactsIterator(params ListParams) *ContactIterator {
+	return &ContactIterator{client: c, pageState: newPageState(params)}
+}
+
+// Next fetches the next page of contacts. It returns an empty, nil-error
+// result once there are no more pages.
+func (it *ContactIterator) Next(ctx context.Context) ([]Contact, error) {
+	if it.done {
+		return nil, nil
+	}
+	page, err := it.client.Contacts(ctx, it.params)
+	if err != nil {
+		return nil, err
+	}
+	it.advance(len(page))
+	return page, nil
+}
+
+// InvoiceIterator pages through Invoices one page at a time, since the
+// Accounting API caps list responses at accountingPageSize records.
+type InvoiceIterator struct {
+	client *APIClient
+	pageState
+}
+
+// InvoicesIterator returns an iterator over every invoice matching params,
+// starting from params.Page (or page 1 if unset).
+func (c *APIClient) InvoicesIterator(params ListParams) *InvoiceIterator {
+	return &InvoiceIterator{client: c, pageState: newPageState(params)}
+}
+
+// Next fetches the next page of invoices. It returns an empty, nil-error
+// result once there are no more pages.
+func (it *InvoiceIterator) Next(ctx context.Context) ([]Invoice, error) {
+	if it.done {
+		return nil, nil
+	}
+	page, err := it.client.Invoices(ctx, it.params)
+	if err != nil {
+		return nil, err
+	}
+	it.advance(len(page))
+	return page, nil
+}
+
+// PaymentIterator pages through Payments one page at a time, since the
+// Accounting API caps list responses at accountingPageSize records.
+type PaymentIterator struct {
+	client *APIClient
+	pageState
+}
+
+// PaymentsIterator returns an iterator over every payment matching params,
+// starting from params.Page (or page 1 if unset).
+func (c *APIClient) PaymentsIterator(params ListParams) *PaymentIterator {
+	return &PaymentIterator{client: c, pageState: newPageState(params)}
+}
+
+// Next fetches the next page of payments. It returns an empty, nil-error
+// result once there are no more pages.
+func (it *PaymentIterator) Next(ctx context.Context) ([]Payment, error) {
+	if it.done {
+		return nil, nil
+	}
+	page, err := it.client.Payments(ctx, it.params)
+	if err != nil {
+		return nil, err
+	}
+	it.advance(len(page))
+	return page, nil
+}
+
+// AccountIterator pages through Accounts one page at a time, since the
+// Accounting API caps list responses at accountingPageSize records.
+type AccountIterator struct {
+	client *APIClient
+	pageState
+}
+
+// AccountsIterator returns an iterator over every account matching params,
+// starting from params.Page (or page 1 if unset).
+func (c *APIClient) AccountsIterator(params ListParams) *AccountIterator {
+	return &AccountIterator{client: c, pageState: newPageState(params)}
+}
+
+// Next fetches the next page of accounts. It returns an empty, nil-error
+// result once there are no more pages.
+func (it *AccountIterator) Next(ctx context.Context) ([]Account, error) {
+	if it.done {
+		return nil, nil
+	}
+	page, err := it.client.Accounts(ctx, it.params)
+	if err != nil {
+		return nil, err
+	}
+	it.advance(len(page))
+	return page, nil
+}
+
+// BankTransactionIterator pages through BankTransactions one page at a
+// time, since the Accounting API caps list responses at
+// accountingPageSize records.
+type BankTransactionIterator struct {
+	client *APIClient
+	pageState
+}
+
+// BankTransactionsIterator returns an iterator over every bank transaction
+// matching params, starting from params.Page (or page 1 if unset).
+func (c *APIClient) BankTransactionsIterator(params ListParams) *BankTransactionIterator {
+	return &BankTransactionIterator{client: c, pageState: newPageState(params)}
+}
+
+// Next fetches the next page of bank transactions. It returns an empty,
+// nil-error result once there are no more pages.
+func (it *BankTransactionIterator) Next(ctx context.Context) ([]BankTransaction, error) {
+	if it.done {
+		return nil, nil
+	}
+	page, err := it.client.BankTransactions(ctx, it.params)
+	if err != nil {
+		return nil, err
+	}
+	it.advance(len(page))
+	return page, nil
+}
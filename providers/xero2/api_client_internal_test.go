@@ -0,0 +1,187 @@
+package xero2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAPIClient(t *testing.T, handler http.HandlerFunc) (*APIClient, func()) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	client := &APIClient{
+		provider:    New("key", "secret", "/foo"),
+		tenantID:    "tenant-id",
+		accessToken: "the-access-token",
+		baseURL:     server.URL,
+	}
+	return client, server.Close
+}
+
+func Test_do_SendsExpectedHeaders(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	modifiedSince := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var gotAuth, gotTenant, gotIfModifiedSince string
+	client, closeServer := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotTenant = r.Header.Get("Xero-Tenant-Id")
+		gotIfModifiedSince = r.Header.Get("If-Modified-Since")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"Contacts": []Contact{}})
+	})
+	defer closeServer()
+
+	_, err := client.Contacts(context.Background(), ListParams{IfModifiedSince: modifiedSince})
+	a.NoError(err)
+	a.Equal("Bearer the-access-token", gotAuth)
+	a.Equal("tenant-id", gotTenant)
+	a.Equal(modifiedSince.UTC().Format(http.TimeFormat), gotIfModifiedSince)
+}
+
+func Test_do_RetriesOnceOn429(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var requests int
+	client, closeServer := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"Contacts": []Contact{{ContactID: "1"}}})
+	})
+	defer closeServer()
+	client.RetryOn429 = true
+
+	contacts, err := client.Contacts(context.Background(), ListParams{})
+	a.NoError(err)
+	a.Equal(2, requests)
+	a.Len(contacts, 1)
+}
+
+func Test_do_StopsRetryingAfterOne429Retry(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var requests int
+	client, closeServer := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer closeServer()
+	client.RetryOn429 = true
+
+	_, err := client.Contacts(context.Background(), ListParams{})
+	a.Error(err)
+	a.Equal(maxRetryOn429+1, requests)
+}
+
+func Test_ContactIterator_StopsAfterShortPage(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	fullPage := make([]Contact, accountingPageSize)
+	for i := range fullPage {
+		fullPage[i] = Contact{ContactID: strconv.Itoa(i)}
+	}
+	shortPage := []Contact{{ContactID: "last"}}
+
+	var requests int
+	client, closeServer := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		page := fullPage
+		if requests > 1 {
+			page = shortPage
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Contacts": page})
+	})
+	defer closeServer()
+
+	it := client.ContactsIterator(ListParams{})
+
+	page, err := it.Next(context.Background())
+	a.NoError(err)
+	a.Len(page, accountingPageSize)
+	a.False(it.done)
+
+	page, err = it.Next(context.Background())
+	a.NoError(err)
+	a.Len(page, 1)
+	a.True(it.done)
+
+	page, err = it.Next(context.Background())
+	a.NoError(err)
+	a.Nil(page)
+	a.Equal(2, requests)
+}
+
+func Test_InvoiceIterator_StopsAfterShortPage(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	fullPage := make([]Invoice, accountingPageSize)
+	for i := range fullPage {
+		fullPage[i] = Invoice{InvoiceID: strconv.Itoa(i)}
+	}
+	shortPage := []Invoice{{InvoiceID: "last"}}
+
+	var requests int
+	client, closeServer := testAPIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		page := fullPage
+		if requests > 1 {
+			page = shortPage
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Invoices": page})
+	})
+	defer closeServer()
+
+	it := client.InvoicesIterator(ListParams{})
+
+	page, err := it.Next(context.Background())
+	a.NoError(err)
+	a.Len(page, accountingPageSize)
+	a.False(it.done)
+
+	page, err = it.Next(context.Background())
+	a.NoError(err)
+	a.Len(page, 1)
+	a.True(it.done)
+
+	page, err = it.Next(context.Background())
+	a.NoError(err)
+	a.Nil(page)
+	a.Equal(2, requests)
+}
+
+func Test_parseRateLimit(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	h := http.Header{}
+	h.Set("X-DayLimit-Remaining", "4999")
+	h.Set("X-MinLimit-Remaining", "58")
+	h.Set("X-AppMinLimit-Remaining", "9990")
+	h.Set("Retry-After", "30")
+
+	rl := parseRateLimit(h)
+	a.Equal(4999, rl.DayLimitRemaining)
+	a.Equal(58, rl.MinLimitRemaining)
+	a.Equal(9990, rl.AppMinLimitRemaining)
+	a.Equal(30*time.Second, rl.RetryAfter)
+}
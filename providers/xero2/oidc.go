@@ -0,0 +1,236 @@
+package xero2
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/plybit/goth"
+)
+
+const (
+	oidcDiscoveryURL = "https://identity.xero.com/.well-known/openid-configuration"
+	oidcIssuer       = "https://identity.xero.com"
+	jwksCacheTTL     = time.Hour
+)
+
+// generateNonce returns a cryptographically random nonce to protect the
+// OpenID Connect flow against ID token replay.
+func generateNonce() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IDTokenClaims holds the claims this package understands from a verified
+// Xero ID token.
+type IDTokenClaims struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	Audience      string `json:"aud"`
+	Expiry        int64  `json:"exp"`
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Name          string `json:"name"`
+	XeroUserID    string `json:"xero_userid"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwksDoc struct {
+	Keys []jwk `json:"keys"`
+}
+
+type oidcDiscoveryDoc struct {
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// verifyIDToken validates rawIDToken's signature, issuer, audience,
+// expiry and nonce, and returns its claims.
+func (p *Provider) verifyIDToken(ctx context.Context, rawIDToken, expectedNonce string) (*IDTokenClaims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("xero2: malformed id_token")
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("xero2: decoding id_token header: %w", err)
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("xero2: parsing id_token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("xero2: unsupported id_token signing algorithm %q", header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("xero2: decoding id_token signature: %w", err)
+	}
+
+	keys, err := p.jwks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := keys[header.Kid]
+	if !ok {
+		return nil, fmt.Errorf("xero2: no JWKS key found for kid %q", header.Kid)
+	}
+
+	signed := parts[0] + "." + parts[1]
+	sum := sha256.Sum256([]byte(signed))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig); err != nil {
+		return nil, fmt.Errorf("xero2: id_token signature verification failed: %w", err)
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("xero2: decoding id_token claims: %w", err)
+	}
+	var claims IDTokenClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, fmt.Errorf("xero2: parsing id_token claims: %w", err)
+	}
+
+	if claims.Issuer != oidcIssuer {
+		return nil, fmt.Errorf("xero2: unexpected id_token issuer %q", claims.Issuer)
+	}
+	if claims.Audience != p.ClientKey {
+		return nil, fmt.Errorf("xero2: unexpected id_token audience %q", claims.Audience)
+	}
+	if time.Now().After(time.Unix(claims.Expiry, 0)) {
+		return nil, errors.New("xero2: id_token has expired")
+	}
+	if expectedNonce != "" && claims.Nonce != expectedNonce {
+		return nil, errors.New("xero2: id_token nonce does not match")
+	}
+
+	return &claims, nil
+}
+
+// jwks returns Xero's current JSON Web Key Set, keyed by kid, fetching
+// and caching it via the OpenID discovery document when necessary.
+func (p *Provider) jwks(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	p.jwksMu.Lock()
+	defer p.jwksMu.Unlock()
+
+	if p.jwksKeys != nil && time.Since(p.jwksFetchedAt) < jwksCacheTTL {
+		return p.jwksKeys, nil
+	}
+
+	var discovery oidcDiscoveryDoc
+	if err := p.getJSON(ctx, oidcDiscoveryURL, &discovery); err != nil {
+		return nil, fmt.Errorf("xero2: fetching OpenID discovery document: %w", err)
+	}
+
+	var doc jwksDoc
+	if err := p.getJSON(ctx, discovery.JWKSURI, &doc); err != nil {
+		return nil, fmt.Errorf("xero2: fetching JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		pub, err := rsaPublicKeyFromJWK(k)
+		if err != nil {
+			return nil, err
+		}
+		keys[k.Kid] = pub
+	}
+
+	p.jwksKeys = keys
+	p.jwksFetchedAt = time.Now()
+	return keys, nil
+}
+
+func rsaPublicKeyFromJWK(k jwk) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("xero2: decoding JWK modulus: %w", err)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("xero2: decoding JWK exponent: %w", err)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(new(big.Int).SetBytes(eBytes).Int64()),
+	}, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received a %d response", resp.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(bits)).Decode(out)
+}
+
+// userFromIDTokenClaims populates user from sess's already-verified ID
+// token claims, without any further network round-trips.
+func (p *Provider) userFromIDTokenClaims(user goth.User, sess *Session) (goth.User, error) {
+	var claims IDTokenClaims
+	if err := json.Unmarshal([]byte(sess.IDTokenClaims), &claims); err != nil {
+		return user, fmt.Errorf("%s: parsing stored id_token claims: %w", p.providerName, err)
+	}
+
+	user.Email = claims.Email
+	user.Name = claims.Name
+	user.FirstName = claims.GivenName
+	user.LastName = claims.FamilyName
+	user.UserID = claims.XeroUserID
+
+	var rawClaims map[string]interface{}
+	if err := json.Unmarshal([]byte(sess.IDTokenClaims), &rawClaims); err != nil {
+		return user, fmt.Errorf("%s: parsing stored id_token claims: %w", p.providerName, err)
+	}
+	user.RawData = map[string]interface{}{"id_token_claims": rawClaims}
+
+	return user, nil
+}
@@ -0,0 +1,284 @@
+package xero2
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedURLTransport redirects requests for specific, hardcoded xero.com
+// endpoints to an httptest.Server, and passes everything else straight
+// through to the real network.
+type fixedURLTransport struct {
+	rewrites map[string]string
+}
+
+func (t fixedURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if to, ok := t.rewrites[req.URL.String()]; ok {
+		u, err := url.Parse(to)
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.URL = u
+		req.Host = u.Host
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func jwkFromRSAPublicKey(pub *rsa.PublicKey, kid string) jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+// newOIDCTestServer serves a discovery document and a JWKS containing pub
+// under kid, and returns a Provider wired (via HTTPClient) to fetch both
+// from it instead of the real identity.xero.com endpoints.
+func newOIDCTestServer(t *testing.T, pub *rsa.PublicKey, kid string) (*httptest.Server, *Provider) {
+	t.Helper()
+
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscoveryDoc{JWKSURI: server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwksDoc{Keys: []jwk{jwkFromRSAPublicKey(pub, kid)}})
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	p := New("the-client-id", "secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: fixedURLTransport{rewrites: map[string]string{
+		oidcDiscoveryURL: server.URL + "/.well-known/openid-configuration",
+	}}}
+	return server, p
+}
+
+func signTestIDToken(t *testing.T, priv *rsa.PrivateKey, kid string, claims IDTokenClaims) string {
+	t.Helper()
+
+	header := `{"alg":"RS256","kid":"` + kid + `"}`
+	headerB64 := base64.RawURLEncoding.EncodeToString([]byte(header))
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimsB64 := base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	signingInput := headerB64 + "." + claimsB64
+	sum := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validTestClaims(clientID string) IDTokenClaims {
+	return IDTokenClaims{
+		Issuer:   oidcIssuer,
+		Subject:  "user-1",
+		Audience: clientID,
+		Expiry:   time.Now().Add(time.Hour).Unix(),
+		Nonce:    "the-nonce",
+		Email:    "jane@example.com",
+	}
+}
+
+func Test_verifyIDToken_AcceptsValidToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	_, p := newOIDCTestServer(t, &priv.PublicKey, "key-1")
+
+	idToken := signTestIDToken(t, priv, "key-1", validTestClaims(p.ClientKey))
+	claims, err := p.verifyIDToken(context.Background(), idToken, "the-nonce")
+	a.NoError(err)
+	a.Equal("jane@example.com", claims.Email)
+}
+
+func Test_verifyIDToken_RejectsTamperedSignature(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	_, p := newOIDCTestServer(t, &priv.PublicKey, "key-1")
+
+	idToken := signTestIDToken(t, priv, "key-1", validTestClaims(p.ClientKey))
+	// The final base64url character of a 256-byte signature carries only
+	// its top two bits of data, so the replacement must differ there.
+	tampered := idToken[:len(idToken)-1]
+	if idToken[len(idToken)-1] == 'A' {
+		tampered += "w"
+	} else {
+		tampered += "A"
+	}
+
+	_, err = p.verifyIDToken(context.Background(), tampered, "the-nonce")
+	a.Error(err)
+}
+
+func Test_verifyIDToken_RejectsExpiredToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	_, p := newOIDCTestServer(t, &priv.PublicKey, "key-1")
+
+	claims := validTestClaims(p.ClientKey)
+	claims.Expiry = time.Now().Add(-time.Hour).Unix()
+	idToken := signTestIDToken(t, priv, "key-1", claims)
+
+	_, err = p.verifyIDToken(context.Background(), idToken, "the-nonce")
+	a.Error(err)
+}
+
+func Test_verifyIDToken_RejectsNonceMismatch(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	_, p := newOIDCTestServer(t, &priv.PublicKey, "key-1")
+
+	idToken := signTestIDToken(t, priv, "key-1", validTestClaims(p.ClientKey))
+	_, err = p.verifyIDToken(context.Background(), idToken, "a-different-nonce")
+	a.Error(err)
+}
+
+func Test_verifyIDToken_RejectsWrongIssuer(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	_, p := newOIDCTestServer(t, &priv.PublicKey, "key-1")
+
+	claims := validTestClaims(p.ClientKey)
+	claims.Issuer = "https://not-xero.example.com"
+	idToken := signTestIDToken(t, priv, "key-1", claims)
+
+	_, err = p.verifyIDToken(context.Background(), idToken, "the-nonce")
+	a.Error(err)
+}
+
+func Test_verifyIDToken_RejectsWrongAudience(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	_, p := newOIDCTestServer(t, &priv.PublicKey, "key-1")
+
+	claims := validTestClaims("a-different-client-id")
+	idToken := signTestIDToken(t, priv, "key-1", claims)
+
+	_, err = p.verifyIDToken(context.Background(), idToken, "the-nonce")
+	a.Error(err)
+}
+
+func Test_Session_Authorize_AcceptsValidIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscoveryDoc{JWKSURI: server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwksDoc{Keys: []jwk{jwkFromRSAPublicKey(&priv.PublicKey, "key-1")}})
+	})
+	mux.HandleFunc("/connect/token", func(w http.ResponseWriter, r *http.Request) {
+		idToken := signTestIDToken(t, priv, "key-1", validTestClaims("the-client-id"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-123",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"refresh_token": "refresh-123",
+			"id_token":      idToken,
+		})
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	p := New("the-client-id", "secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: fixedURLTransport{rewrites: map[string]string{
+		oidcDiscoveryURL: server.URL + "/.well-known/openid-configuration",
+		tokenURL:         server.URL + "/connect/token",
+	}}}
+
+	sess := &Session{Nonce: "the-nonce"}
+	accessToken, err := sess.Authorize(p, url.Values{"code": {"the-code"}})
+	a.NoError(err)
+	a.Equal("access-123", accessToken)
+	a.Empty(sess.Nonce)
+	a.Contains(sess.IDTokenClaims, "jane@example.com")
+}
+
+func Test_Session_Authorize_RejectsNonceMismatch(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscoveryDoc{JWKSURI: server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwksDoc{Keys: []jwk{jwkFromRSAPublicKey(&priv.PublicKey, "key-1")}})
+	})
+	mux.HandleFunc("/connect/token", func(w http.ResponseWriter, r *http.Request) {
+		idToken := signTestIDToken(t, priv, "key-1", validTestClaims("the-client-id"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-123",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	p := New("the-client-id", "secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: fixedURLTransport{rewrites: map[string]string{
+		oidcDiscoveryURL: server.URL + "/.well-known/openid-configuration",
+		tokenURL:         server.URL + "/connect/token",
+	}}}
+
+	sess := &Session{Nonce: "a-different-nonce"}
+	_, err = sess.Authorize(p, url.Values{"code": {"the-code"}})
+	a.Error(err)
+}
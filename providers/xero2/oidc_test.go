@@ -0,0 +1,22 @@
+package xero2_test
+
+import (
+	"testing"
+
+	"github.com/plybit/goth/providers/xero2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BeginAuth_SetsNonce(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+
+	s := session.(*xero2.Session)
+	a.NotEmpty(s.Nonce)
+	a.Contains(s.AuthURL, "nonce=")
+}
+
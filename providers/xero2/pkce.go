@@ -0,0 +1,28 @@
+package xero2
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// pkceVerifierLength is the number of random bytes used to build the code
+// verifier. Base64url-encoding 66 bytes yields an 88 character string,
+// comfortably inside the 43-128 character range required by RFC 7636.
+const pkceVerifierLength = 66
+
+// generateCodeVerifier returns a cryptographically random PKCE code
+// verifier made up only of the unreserved characters RFC 7636 allows.
+func generateCodeVerifier() (string, error) {
+	b := make([]byte, pkceVerifierLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// codeChallengeS256 derives the S256 code challenge for the given verifier.
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,49 @@
+package xero2_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/plybit/goth/providers/xero2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BeginAuth_PKCE_DefaultsOnForPublicClients(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := xero2.New("key", "", "/foo")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+
+	s := session.(*xero2.Session)
+	a.NotEmpty(s.CodeVerifier)
+	a.Contains(s.AuthURL, "code_challenge=")
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+}
+
+func Test_BeginAuth_PKCE_OffForConfidentialClients(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := xero2.New("key", "secret", "/foo")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+
+	s := session.(*xero2.Session)
+	a.Empty(s.CodeVerifier)
+	a.False(strings.Contains(s.AuthURL, "code_challenge="))
+}
+
+func Test_BeginAuth_PKCE_ExplicitOverride(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := xero2.New("key", "secret", "/foo")
+	p.SetPKCE(true)
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+
+	s := session.(*xero2.Session)
+	a.NotEmpty(s.CodeVerifier)
+}
@@ -0,0 +1,72 @@
+package xero2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/plybit/goth"
+)
+
+const revocationURL = "https://identity.xero.com/connect/revocation"
+
+// RevocationHint tells Xero's revocation endpoint which kind of token is
+// being revoked, per RFC 7009.
+type RevocationHint string
+
+const (
+	RevocationHintAccessToken  RevocationHint = "access_token"
+	RevocationHintRefreshToken RevocationHint = "refresh_token"
+)
+
+// Revoke asks Xero to revoke token, identified to the revocation endpoint
+// via the RFC 7009 token_type_hint derived from hint.
+func (p *Provider) Revoke(ctx context.Context, token string, hint RevocationHint) error {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {string(hint)},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", revocationURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(p.ClientKey, p.Secret)
+
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to revoke a token", p.providerName, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Logout revokes both the access token and, if present, the refresh token
+// held by session, then clears them so the session can no longer be used
+// to authenticate requests.
+func (p *Provider) Logout(ctx context.Context, session goth.Session) error {
+	sess := session.(*Session)
+
+	if sess.AccessToken != "" {
+		if err := p.Revoke(ctx, sess.AccessToken, RevocationHintAccessToken); err != nil {
+			return err
+		}
+	}
+	if sess.RefreshToken != "" {
+		if err := p.Revoke(ctx, sess.RefreshToken, RevocationHintRefreshToken); err != nil {
+			return err
+		}
+	}
+
+	sess.AccessToken = ""
+	sess.RefreshToken = ""
+	return nil
+}
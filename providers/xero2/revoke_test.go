@@ -0,0 +1,90 @@
+package xero2_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/plybit/goth/providers/xero2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Logout_ClearsSessionWithoutTokens(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	s := &xero2.Session{}
+
+	a.NoError(p.Logout(context.Background(), s))
+	a.Empty(s.AccessToken)
+	a.Empty(s.RefreshToken)
+}
+
+func Test_Revoke_PostsTokenAndBasicAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var gotUser, gotPass string
+	var gotOK bool
+	var gotForm url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, gotPass, gotOK = r.BasicAuth()
+		body, _ := ioutil.ReadAll(r.Body)
+		gotForm, _ = url.ParseQuery(string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := xero2.New("the-client-id", "the-secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: fixedURLTransport{rewrites: map[string]string{
+		"https://identity.xero.com/connect/revocation": server.URL,
+	}}}
+
+	err := p.Revoke(context.Background(), "the-token", xero2.RevocationHintRefreshToken)
+	a.NoError(err)
+	a.True(gotOK)
+	a.Equal("the-client-id", gotUser)
+	a.Equal("the-secret", gotPass)
+	a.Equal("the-token", gotForm.Get("token"))
+	a.Equal("refresh_token", gotForm.Get("token_type_hint"))
+}
+
+func Test_Revoke_SurfacesNon200AsError(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	p := xero2.New("the-client-id", "the-secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: fixedURLTransport{rewrites: map[string]string{
+		"https://identity.xero.com/connect/revocation": server.URL,
+	}}}
+
+	err := p.Revoke(context.Background(), "the-token", xero2.RevocationHintAccessToken)
+	a.Error(err)
+}
+
+func Test_Logout_SurfacesRevokeErrorForAccessToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	p := xero2.New("the-client-id", "the-secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: fixedURLTransport{rewrites: map[string]string{
+		"https://identity.xero.com/connect/revocation": server.URL,
+	}}}
+
+	s := &xero2.Session{AccessToken: "the-access-token"}
+	a.Error(p.Logout(context.Background(), s))
+}
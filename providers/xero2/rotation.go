@@ -0,0 +1,166 @@
+package xero2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrRefreshTokenReuse is returned by RefreshTokenWithRotation when a
+// refresh token that has already been superseded by a newer one is
+// redeemed again. Per RFC 6819 section 5.2.2.3, Xero treats this as a
+// signal that the refresh token has leaked, so the whole grant family
+// must be invalidated and the caller must force the user to re-authenticate.
+var ErrRefreshTokenReuse = errors.New("xero2: refresh token reuse detected, grant family invalidated")
+
+// RotatedToken is the result of a refresh performed through
+// RefreshTokenWithRotation. Xero issues a new refresh token on every
+// refresh and the old one becomes unusable, so callers need to know
+// both what changed and whether rotation actually happened.
+type RotatedToken struct {
+	*oauth2.Token
+	PreviousRefreshToken string
+	Rotated              bool
+	IssuedAt             time.Time
+}
+
+// RefreshTokenRecord is the state RefreshTokenStore persists for a single
+// grant family so reuse of a stale refresh token can be detected.
+type RefreshTokenRecord struct {
+	ClientID         string
+	FamilyID         string
+	RefreshTokenHash string
+	Nonce            int64
+	Invalidated      bool
+}
+
+// RefreshTokenStore persists refresh token rotation state across requests
+// so RefreshTokenWithRotation can detect when a superseded refresh token
+// is redeemed a second time.
+type RefreshTokenStore interface {
+	Load(clientID, familyID string) (RefreshTokenRecord, error)
+	Store(record RefreshTokenRecord) error
+	Invalidate(familyID string) error
+}
+
+// SetRefreshTokenStore wires up the RefreshTokenStore that
+// RefreshTokenWithRotation persists rotation state to. It must be called
+// once, typically right after New, before RefreshTokenWithRotation is used;
+// an in-memory store is fine for a single process, but most deployments
+// will want one backed by whatever database already holds the user's
+// session so rotation state survives restarts.
+func (p *Provider) SetRefreshTokenStore(store RefreshTokenStore) {
+	p.refreshStore = store
+}
+
+// RefreshTokenWithRotation refreshes oldRefresh and records the rotation in
+// the store configured via SetRefreshTokenStore, under familyID. familyID
+// is a stable identifier the caller mints once per grant (e.g. when the
+// user first authorizes) and stores alongside the refresh token; unlike
+// the store, it can't be configured once on the Provider because a single
+// Provider serves every user's grant. If oldRefresh no longer matches the
+// newest token the store has on record for that family, the family is
+// invalidated and ErrRefreshTokenReuse is returned.
+func (p *Provider) RefreshTokenWithRotation(ctx context.Context, familyID, oldRefresh string) (*RotatedToken, error) {
+	if p.refreshStore == nil {
+		return nil, errors.New("xero2: no RefreshTokenStore configured, call SetRefreshTokenStore first")
+	}
+
+	record, err := p.refreshStore.Load(p.ClientKey, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.RefreshTokenHash != "" {
+		if record.Invalidated {
+			return nil, ErrRefreshTokenReuse
+		}
+		if record.RefreshTokenHash != hashRefreshToken(oldRefresh) {
+			if invalidateErr := p.refreshStore.Invalidate(familyID); invalidateErr != nil {
+				return nil, invalidateErr
+			}
+			return nil, ErrRefreshTokenReuse
+		}
+	}
+
+	token := &oauth2.Token{RefreshToken: oldRefresh}
+	ts := p.config.TokenSource(context.WithValue(ctx, oauth2.HTTPClient, p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	rotated := newToken.RefreshToken != "" && newToken.RefreshToken != oldRefresh
+	if err := p.refreshStore.Store(RefreshTokenRecord{
+		ClientID:         p.ClientKey,
+		FamilyID:         familyID,
+		RefreshTokenHash: hashRefreshToken(newToken.RefreshToken),
+		Nonce:            record.Nonce + 1,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &RotatedToken{
+		Token:                newToken,
+		PreviousRefreshToken: oldRefresh,
+		Rotated:              rotated,
+		IssuedAt:             time.Now(),
+	}, nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// InMemoryRefreshTokenStore is a RefreshTokenStore suitable for tests and
+// single-process deployments. It is not durable across restarts.
+type InMemoryRefreshTokenStore struct {
+	mu      sync.Mutex
+	records map[string]RefreshTokenRecord
+}
+
+// NewInMemoryRefreshTokenStore creates an empty InMemoryRefreshTokenStore.
+func NewInMemoryRefreshTokenStore() *InMemoryRefreshTokenStore {
+	return &InMemoryRefreshTokenStore{records: map[string]RefreshTokenRecord{}}
+}
+
+// Load returns the record stored for familyID, or a zero-value record if
+// none has been stored yet.
+func (s *InMemoryRefreshTokenStore) Load(clientID, familyID string) (RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key(clientID, familyID)], nil
+}
+
+// Store saves record, keyed by its ClientID and FamilyID.
+func (s *InMemoryRefreshTokenStore) Store(record RefreshTokenRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key(record.ClientID, record.FamilyID)] = record
+	return nil
+}
+
+// Invalidate marks the family's record so any further refresh attempt
+// fails with ErrRefreshTokenReuse.
+func (s *InMemoryRefreshTokenStore) Invalidate(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, record := range s.records {
+		if record.FamilyID == familyID {
+			record.Invalidated = true
+			s.records[k] = record
+		}
+	}
+	return nil
+}
+
+func key(clientID, familyID string) string {
+	return fmt.Sprintf("%s:%s", clientID, familyID)
+}
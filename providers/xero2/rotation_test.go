@@ -0,0 +1,86 @@
+package xero2_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/plybit/goth/providers/xero2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_InMemoryRefreshTokenStore_LoadMissing(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	store := xero2.NewInMemoryRefreshTokenStore()
+	record, err := store.Load("client", "family-1")
+	a.NoError(err)
+	a.Equal(xero2.RefreshTokenRecord{}, record)
+}
+
+func Test_InMemoryRefreshTokenStore_StoreAndLoad(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	store := xero2.NewInMemoryRefreshTokenStore()
+	a.NoError(store.Store(xero2.RefreshTokenRecord{
+		ClientID:         "client",
+		FamilyID:         "family-1",
+		RefreshTokenHash: "abc",
+		Nonce:            1,
+	}))
+
+	record, err := store.Load("client", "family-1")
+	a.NoError(err)
+	a.Equal("abc", record.RefreshTokenHash)
+	a.False(record.Invalidated)
+}
+
+func Test_InMemoryRefreshTokenStore_Invalidate(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	store := xero2.NewInMemoryRefreshTokenStore()
+	a.NoError(store.Store(xero2.RefreshTokenRecord{
+		ClientID:         "client",
+		FamilyID:         "family-1",
+		RefreshTokenHash: "abc",
+		Nonce:            1,
+	}))
+	a.NoError(store.Invalidate("family-1"))
+
+	record, err := store.Load("client", "family-1")
+	a.NoError(err)
+	a.True(record.Invalidated)
+}
+
+func Test_RefreshTokenWithRotation_DetectsReuse(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := xero2.New("key", "secret", "/foo")
+	store := xero2.NewInMemoryRefreshTokenStore()
+	p.SetRefreshTokenStore(store)
+	a.NoError(store.Store(xero2.RefreshTokenRecord{
+		ClientID:         "key",
+		FamilyID:         "family-1",
+		RefreshTokenHash: "the-current-hash",
+		Nonce:            1,
+	}))
+
+	_, err := p.RefreshTokenWithRotation(context.Background(), "family-1", "a-stale-refresh-token")
+	a.ErrorIs(err, xero2.ErrRefreshTokenReuse)
+
+	record, loadErr := store.Load("key", "family-1")
+	a.NoError(loadErr)
+	a.True(record.Invalidated)
+}
+
+func Test_RefreshTokenWithRotation_RequiresStore(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := xero2.New("key", "secret", "/foo")
+	_, err := p.RefreshTokenWithRotation(context.Background(), "family-1", "a-refresh-token")
+	a.Error(err)
+}
@@ -0,0 +1,108 @@
+package xero2
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/plybit/goth"
+	"golang.org/x/oauth2"
+)
+
+// Session stores data during the auth process with Xero.
+type Session struct {
+	AuthURL      string
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+
+	// TenantID, when set before FetchUser is called, selects which
+	// authorized Xero organization to hydrate the goth.User from. Leave
+	// empty to fall back to the first tenant returned by the connections
+	// endpoint. It has no effect when IDTokenClaims is set, since FetchUser
+	// then populates the user from the claims alone.
+	TenantID string
+
+	// CodeVerifier holds the PKCE code verifier generated in BeginAuth,
+	// if PKCE is enabled on the provider. It is sent back to Xero during
+	// the token exchange in Authorize, and zeroed as soon as Authorize has
+	// read it, before the exchange request is made.
+	CodeVerifier string
+
+	// Nonce is generated in BeginAuth and sent to Xero so the ID token
+	// returned on exchange can be checked for replay. Authorize zeroes it
+	// after a successful exchange, before the ID token is verified against
+	// it, so it is cleared whether or not that verification succeeds.
+	Nonce string
+
+	// IDTokenClaims holds the JSON-encoded, already-verified claims from
+	// the ID token returned on exchange, if the provider's OpenID scopes
+	// were granted. FetchUser uses it to populate identity fields without
+	// an extra round-trip to the Organisation endpoint.
+	IDTokenClaims string
+}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the Xero provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session with Xero and return the access token to be stored for future use.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+
+	var opts []oauth2.AuthCodeOption
+	if s.CodeVerifier != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", s.CodeVerifier))
+	}
+	s.CodeVerifier = ""
+
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), opts...)
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid() {
+		return "", errors.New("Invalid token received from provider")
+	}
+
+	nonce := s.Nonce
+	s.Nonce = ""
+	if rawIDToken, ok := token.Extra("id_token").(string); ok && rawIDToken != "" {
+		claims, err := p.verifyIDToken(goth.ContextForClient(p.Client()), rawIDToken, nonce)
+		if err != nil {
+			return "", err
+		}
+		claimsJSON, err := json.Marshal(claims)
+		if err != nil {
+			return "", err
+		}
+		s.IDTokenClaims = string(claimsJSON)
+	}
+
+	s.AccessToken = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	s.ExpiresAt = token.Expiry
+	return token.AccessToken, err
+}
+
+// Marshal the session into a string.
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return s.Marshal()
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
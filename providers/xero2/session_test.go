@@ -36,7 +36,7 @@ func Test_ToJSON(t *testing.T) {
 	s := &xero2.Session{}
 
 	data := s.Marshal()
-	a.Equal(data, `{"AuthURL":"","AccessToken":"","Hostname":"","HMAC":"","ExpiresAt":"0001-01-01T00:00:00Z"}`)
+	a.Equal(data, `{"AuthURL":"","AccessToken":"","RefreshToken":"","ExpiresAt":"0001-01-01T00:00:00Z","TenantID":"","CodeVerifier":"","Nonce":"","IDTokenClaims":""}`)
 }
 
 func Test_String(t *testing.T) {
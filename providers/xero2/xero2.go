@@ -4,11 +4,14 @@ package xero2
 
 import (
 	"bytes"
+	"crypto/rsa"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/plybit/goth"
 	"golang.org/x/oauth2"
@@ -41,6 +44,13 @@ type Provider struct {
 	config       *oauth2.Config
 	providerName string
 	scopes       []string
+	pkce         *bool
+
+	jwksMu        sync.Mutex
+	jwksKeys      map[string]*rsa.PublicKey
+	jwksFetchedAt time.Time
+
+	refreshStore RefreshTokenStore
 }
 
 // New creates a new xero provider and sets up important connection details.
@@ -76,11 +86,46 @@ func (p *Provider) SetName(name string) {
 // Debug is a no-op for the xero package.
 func (p *Provider) Debug(debug bool) {}
 
+// SetPKCE turns PKCE (RFC 7636) on or off for the authorization code flow.
+// When it has not been called, PKCE defaults to on for public clients
+// (those created with an empty Secret) and off otherwise.
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = &enabled
+}
+
+func (p *Provider) usePKCE() bool {
+	if p.pkce != nil {
+		return *p.pkce
+	}
+	return p.Secret == ""
+}
+
 // BeginAuth asks xero for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
-	}, nil
+	sess := &Session{}
+
+	var opts []oauth2.AuthCodeOption
+	if p.usePKCE() {
+		verifier, err := generateCodeVerifier()
+		if err != nil {
+			return nil, err
+		}
+		sess.CodeVerifier = verifier
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", codeChallengeS256(verifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+	}
+
+	nonce, err := generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	sess.Nonce = nonce
+	opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce))
+
+	sess.AuthURL = p.config.AuthCodeURL(state, opts...)
+	return sess, nil
 }
 
 //RefreshTokenAvailable refresh token is provided by auth provider or not
@@ -114,8 +159,10 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get organization information without accessToken", p.providerName)
 	}
 
-	// Find the tenants authorized at xero.
-	tenants, err := p.fetchAuthorizedTenants(sess)
+	// Find the tenants authorized at xero so a picker can be rendered,
+	// regardless of whether the ID token also lets us skip the
+	// Organisation GET below.
+	tenants, err := p.FetchTenants(sess)
 	if err != nil {
 		return user, err
 	}
@@ -123,8 +170,65 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, errors.New("No authorized xero tenants found")
 	}
 
-	// Find the organization/tenant info from xero.
-	return p.fetchTenantInformation(user, sess, tenants[0].TenantID)
+	tenantID := sess.TenantID
+	if tenantID == "" {
+		tenantID = tenants[0].TenantID
+	}
+
+	if sess.IDTokenClaims != "" {
+		// The ID token already carries everything we need to identify the
+		// user, so skip the extra Organisation round-trip - but still
+		// surface the tenant list so picker UIs keep working. The selected
+		// tenant is not used on this path: no organization is fetched.
+		user, err = p.userFromIDTokenClaims(user, sess)
+		if err != nil {
+			return user, err
+		}
+	} else {
+		// Find the organization/tenant info from xero.
+		user, err = p.fetchTenantInformation(user, sess, tenantID)
+		if err != nil {
+			return user, err
+		}
+	}
+
+	if user.RawData == nil {
+		user.RawData = map[string]interface{}{}
+	}
+	user.RawData["tenants"] = tenants
+
+	return user, nil
+}
+
+// FetchTenants returns every Xero organization the session's access token
+// is authorized against, as reported by the connections endpoint. Callers
+// can use this to build a tenant picker and set Session.TenantID before
+// calling FetchUser or FetchUserForTenant.
+func (p *Provider) FetchTenants(session goth.Session) ([]*XeroTenant, error) {
+	sess := session.(*Session)
+	return p.fetchAuthorizedTenants(sess)
+}
+
+// FetchUserForTenant hydrates the user from the Organisation record of the
+// given tenantID rather than Session.TenantID or the first authorized
+// tenant, so a caller that already knows which organization it wants
+// doesn't need to mutate the session first. Unlike FetchUser it always
+// makes the Organisation request, ignores Session.IDTokenClaims, and does
+// not fetch the tenant list or add it to RawData.
+func (p *Provider) FetchUserForTenant(session goth.Session, tenantID string) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+	}
+
+	if user.AccessToken == "" {
+		return user, fmt.Errorf("%s cannot get organization information without accessToken", p.providerName)
+	}
+
+	return p.fetchTenantInformation(user, sess, tenantID)
 }
 
 func newConfig(p *Provider, scopes []string) *oauth2.Config {
@@ -239,9 +343,13 @@ func (p *Provider) fetchTenantInformation(user goth.User, sess *Session, tenantI
 }
 
 type XeroTenant struct {
-	ID         string `json:"id,omitempty"`
-	TenantID   string `json:"tenantId,omitempty"`
-	TenantType string `json:"tenantType,omitempty"`
+	ID             string    `json:"id,omitempty"`
+	AuthEventID    string    `json:"authEventId,omitempty"`
+	TenantID       string    `json:"tenantId,omitempty"`
+	TenantType     string    `json:"tenantType,omitempty"`
+	TenantName     string    `json:"tenantName,omitempty"`
+	CreatedDateUTC time.Time `json:"createdDateUtc,omitempty"`
+	UpdatedDateUTC time.Time `json:"updatedDateUtc,omitempty"`
 }
 
 type XeroOrganization struct {
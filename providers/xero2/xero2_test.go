@@ -1,6 +1,10 @@
 package xero2_test
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
@@ -48,7 +52,85 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_FetchUserForTenant_RequiresAccessToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	_, err := p.FetchUserForTenant(&xero2.Session{}, "some-tenant-id")
+	a.Error(err)
+}
+
+func Test_XeroTenant_JSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	raw := `{"id":"1","authEventId":"2","tenantId":"3","tenantType":"ORGANISATION","tenantName":"Acme","createdDateUtc":"2020-01-01T00:00:00Z","updatedDateUtc":"2020-01-02T00:00:00Z"}`
+	var tenant xero2.XeroTenant
+	a.NoError(json.Unmarshal([]byte(raw), &tenant))
+	a.Equal("2", tenant.AuthEventID)
+	a.Equal("Acme", tenant.TenantName)
+}
+
 func provider() *xero2.Provider {
 	p := xero2.New(os.Getenv("XERO_KEY"), os.Getenv("XERO_SECRET"), "/foo")
 	return p
 }
+
+// fixedURLTransport redirects requests for specific, hardcoded xero.com
+// endpoints to an httptest.Server, and passes everything else straight
+// through to the real network.
+type fixedURLTransport struct {
+	rewrites map[string]string
+}
+
+func (t fixedURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if to, ok := t.rewrites[req.URL.String()]; ok {
+		u, err := url.Parse(to)
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.URL = u
+		req.Host = u.Host
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func Test_FetchUser_SelectsTenantFromSession(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	tenants := []xero2.XeroTenant{
+		{TenantID: "tenant-1", TenantName: "Acme"},
+		{TenantID: "tenant-2", TenantName: "Widgets Co"},
+	}
+
+	var gotTenantID string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/connections", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(tenants)
+	})
+	mux.HandleFunc("/api.xro/2.0/Organisation", func(w http.ResponseWriter, r *http.Request) {
+		gotTenantID = r.Header.Get("Xero-Tenant-Id")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Organisations": []xero2.XeroOrganization{{Name: "Widgets Co", LegalName: "Widgets Co Ltd", ShortCode: "WID"}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p := provider()
+	p.HTTPClient = &http.Client{Transport: fixedURLTransport{rewrites: map[string]string{
+		"https://api.xero.com/connections":              server.URL + "/connections",
+		"https://api.xero.com/api.xro/2.0/Organisation": server.URL + "/api.xro/2.0/Organisation",
+	}}}
+
+	sess := &xero2.Session{AccessToken: "the-access-token", TenantID: "tenant-2"}
+	user, err := p.FetchUser(sess)
+	a.NoError(err)
+	a.Equal("tenant-2", gotTenantID)
+	a.Equal("Widgets Co", user.Name)
+	a.Equal(tenants[0].TenantID, user.RawData["tenants"].([]*xero2.XeroTenant)[0].TenantID)
+	a.Equal(tenants[1].TenantID, user.RawData["tenants"].([]*xero2.XeroTenant)[1].TenantID)
+}